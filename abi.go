@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// abiFlag selects the Go calling convention the generated TEXT symbol uses.
+var abiFlag = flag.String("abi", "abi0", "Go calling convention for the generated symbol: abi0 (default, stack-based args) or internal (register-based, Go 1.17+)")
+
+// goABIInternalRegisters lists the registers the Go internal ABI (Go 1.17+)
+// passes the first nine integer/pointer arguments in, in order.
+var goABIInternalRegisters = [...]string{"AX", "BX", "CX", "DI", "SI", "R8", "R9", "R10", "R11"}
+
+// Register mapping for the <ABIInternal> entry point (Go 1.17+ internal ABI
+// -> System V AMD64, the convention the clang-compiled body was built
+// against):
+//
+//	Go ABIInternal arg    AX   BX   CX   DI   SI   R8   R9   R10   R11
+//	System V arg           1    2    3    4    5    6    7     8     9
+//	System V register     DI   SI   DX   CX   R8   R9  stack stack stack
+//
+// Arguments 7-9 don't fit System V's six integer argument registers, so they
+// are written to the stack at returnAddrOnStack + 8*len(registers)(SP) and
+// up, 8 bytes apart: the slots fixRbpPlusLoad rewrites the body's
+// stack-argument loads to read from. Return values are not translated: a single integer result
+// happens to already sit in AX under both conventions, but multi-value and
+// floating-point results are not handled by this wrapper.
+func writeGoasmPrologueABIInternal(subroutine Subroutine, arguments int, table Table) []string {
+
+	var result []string
+
+	if arguments > len(goABIInternalRegisters) {
+		panic(fmt.Sprintf("%s: ABIInternal wrapper supports at most %d integer arguments, got %d", subroutine.name, len(goABIInternalRegisters), arguments))
+	}
+
+	result = append(result, fmt.Sprintf("TEXT ·_%s<ABIInternal>(SB), NOSPLIT, $0\n", subroutine.name))
+
+	for arg := 0; arg < arguments && arg < len(registers); arg++ {
+		result = append(result, fmt.Sprintf("    MOVQ %s, %s", goABIInternalRegisters[arg], registers[arg]))
+	}
+
+	for arg := len(registers); arg < arguments; arg++ {
+		// Must land at the same physical slot fixRbpPlusLoad/flavor.FixRbpPlusLoad
+		// compute for a stack-based argument: returnAddrOnStack + 8*len(registers)
+		// for the first stack arg, +8 per argument after that.
+		offset := returnAddrOnStack + 8*len(registers) + (arg-len(registers))*8
+		result = append(result, fmt.Sprintf("    MOVQ %s, %d(SP)", goABIInternalRegisters[arg], offset))
+	}
+
+	if table.isPresent() {
+		result = append(result, "", fmt.Sprintf("    LEAQ %s<>(SB), BP", table.Name), "")
+	}
+
+	if subroutine.epilogue.StackSize != 0 {
+		result = append(result, fmt.Sprintf("    SUBQ $%d, SP", subroutine.epilogue.StackSize))
+	}
+
+	return result
+}
+
+// writeGoasmABI0Shim emits the paired <ABI0> entry point that Go 1.17+ still
+// needs for callers built against the stack-based convention: it loads the
+// stack arguments into the internal-ABI registers, CALLs the <ABIInternal>
+// entry point and returns.
+func writeGoasmABI0Shim(subroutine Subroutine, arguments int) []string {
+
+	var result []string
+
+	result = append(result, fmt.Sprintf("TEXT ·_%s(SB), NOSPLIT, $0\n", subroutine.name))
+
+	for arg := 0; arg < arguments && arg < len(goABIInternalRegisters); arg++ {
+		result = append(result, fmt.Sprintf("    MOVQ arg%d+%d(FP), %s", arg+1, arg*8, goABIInternalRegisters[arg]))
+	}
+	result = append(result, fmt.Sprintf("    CALL ·_%s<ABIInternal>(SB)", subroutine.name))
+	result = append(result, "    RET")
+
+	return result
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+var regexpTestRspOffset = regexp.MustCompile(`(-?\d+)\[rsp\]`)
+var regexpTestMovqStoreSP = regexp.MustCompile(`MOVQ (\w+), (-?\d+)\(SP\)`)
+
+// TestWriteGoasmPrologueABIInternalStackArgs verifies that arguments 7-9,
+// written to the stack by the <ABIInternal> prologue, land at the same
+// physical SP offset that fixRbpPlusLoad computes for a stack-based argument
+// at the equivalent position in the original clang .s file.
+func TestWriteGoasmPrologueABIInternalStackArgs(t *testing.T) {
+
+	subroutine := Subroutine{name: "nineargs"}
+	prologue := writeGoasmPrologueABIInternal(subroutine, 9, Table{})
+
+	tests := []struct {
+		arg            int // 0-based argument index (6, 7, 8 -> 7th, 8th, 9th arg)
+		rbpOffsetFirst int // the [rbp + N] offset clang used for this same argument
+	}{
+		{arg: 6, rbpOffsetFirst: 0},
+		{arg: 7, rbpOffsetFirst: 8},
+		{arg: 8, rbpOffsetFirst: 16},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("arg%d", test.arg+1), func(t *testing.T) {
+
+			reg := goABIInternalRegisters[test.arg]
+
+			var wroteOffset string
+			for _, line := range prologue {
+				if match := regexpTestMovqStoreSP.FindStringSubmatch(line); len(match) > 0 && match[1] == reg {
+					wroteOffset = match[2]
+				}
+			}
+			if wroteOffset == "" {
+				t.Fatalf("prologue never stores argument %d from %s to the stack: %v", test.arg+1, reg, prologue)
+			}
+
+			// The same [rbp + N] load, rewritten by the shared SysV rewrite
+			// every other stack-argument read in the body goes through.
+			stackArgs := StackArgs{OffsetToFirst: 0}
+			line := fmt.Sprintf("mov eax, [rbp + %d]", test.rbpOffsetFirst)
+			decoded, err := DecodeInstruction(line, 1)
+			if err != nil {
+				t.Fatalf("DecodeInstruction: %v", err)
+			}
+			rewritten := fixRbpPlusLoad(line, stackArgs, false, decoded)
+
+			match := regexpTestRspOffset.FindStringSubmatch(rewritten)
+			if match == nil {
+				t.Fatalf("fixRbpPlusLoad did not produce an [rsp]-relative load: %q", rewritten)
+			}
+			readOffset := match[1]
+
+			if wroteOffset != readOffset {
+				t.Errorf("ABIInternal prologue writes arg %d to %s(SP), but the body reads it from %s[rsp]: offsets must match", test.arg+1, wroteOffset, readOffset)
+			}
+		})
+	}
+}
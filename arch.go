@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// archFlag selects the target architecture of the generated Go assembly.
+// Only the instruction-set specific parts of the pipeline (register
+// conventions, label/jump/call rewriting, prologue/epilogue shape) vary
+// between architectures. Each Arch runs its own body loop (writeGoasmBody
+// for amd64, ARM64Arch.WriteBody for arm64); comment stripping
+// (stripComments) and the decoder pass (DecodeInstruction) are shared.
+var archFlag = flag.String("arch", "amd64", "target architecture for the generated Go assembly: amd64 or arm64")
+
+// Arch captures everything about a target instruction set that the
+// translation pipeline needs: which registers carry the first integer
+// arguments, and how to render the prologue/body/epilogue for that ISA.
+type Arch interface {
+	// Name returns the short identifier used on the command line (eg "amd64").
+	Name() string
+	// Registers returns the (ordered) argument registers for the platform's calling convention.
+	Registers() []string
+	// WritePrologue renders the TEXT header and argument-loading prologue for subroutine.
+	WritePrologue(subroutine Subroutine, arguments int, table Table) []string
+	// WriteBody rewrites the clang-produced instructions into their Go assembler equivalents.
+	WriteBody(lines []string, table Table, stackArgs StackArgs, epilogue Epilogue) ([]string, error)
+	// WriteEpilogue renders the stack-teardown and return sequence for the subroutine.
+	WriteEpilogue(epilogue Epilogue) []string
+}
+
+// SelectArch resolves the -arch flag (or an explicit name) to its Arch implementation.
+func SelectArch(name string) (Arch, error) {
+	switch name {
+	case "amd64", "":
+		return AMD64Arch{}, nil
+	case "arm64":
+		return ARM64Arch{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported architecture %q: expected amd64 or arm64", name)
+	}
+}
+
+// AMD64Arch is the original, System V AMD64 backend; it simply delegates to
+// the package-level functions that predate the multi-arch split.
+type AMD64Arch struct{}
+
+func (AMD64Arch) Name() string        { return "amd64" }
+func (AMD64Arch) Registers() []string { return registers[:] }
+func (a AMD64Arch) WritePrologue(subroutine Subroutine, arguments int, table Table) []string {
+
+	if *callconvFlag == "msvc" {
+		return writeGoasmPrologueMSVC(subroutine, arguments, table)
+	}
+
+	if *abiFlag == "internal" {
+		// The ABI0 shim is a complete, self-contained TEXT block (it RETs on
+		// its own), so it must come first: the body and epilogue that follow
+		// whatever WritePrologue returns belong to the <ABIInternal> entry point.
+		var result []string
+		result = append(result, writeGoasmABI0Shim(subroutine, arguments)...)
+		result = append(result, "")
+		result = append(result, writeGoasmPrologueABIInternal(subroutine, arguments, table)...)
+		return result
+	}
+
+	return writeGoasmPrologue(subroutine, arguments, table)
+}
+
+func (a AMD64Arch) WriteBody(lines []string, table Table, stackArgs StackArgs, epilogue Epilogue) ([]string, error) {
+
+	flavor, err := SelectFlavor(*flavorFlag)
+	if err != nil {
+		return nil, err
+	}
+	if *flavorFlag == "" {
+		flavor = DetectFlavor(lines)
+	}
+
+	return writeGoasmBody(lines, table, stackArgs, epilogue, flavor)
+}
+
+func (a AMD64Arch) WriteEpilogue(epilogue Epilogue) []string {
+	return writeGoasmEpilogue(epilogue)
+}
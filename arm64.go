@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// arm64Registers holds the AAPCS64 integer argument registers (X0..X7) in order.
+var arm64Registers = [...]string{"R0", "R1", "R2", "R3", "R4", "R5", "R6", "R7"}
+
+var regexpArm64Call = regexp.MustCompile(`^\s*bl\s+_?(\w+)`)
+var regexpArm64Label = regexp.MustCompile(`^(\.?LBB.*:)`)
+var regexpArm64Branch = regexp.MustCompile(`^(\s*(?:b|b\.\w+|cbz|cbnz|tbz|tbnz))\s+(?:(\w+),\s*)?(?:#(\d+),\s*)?(\.?LBB\S*)`)
+var regexpArm64Adrp = regexp.MustCompile(`^\s*adrp\s+(x\d+),\s*(\S+)`)
+var regexpArm64AddLo12 = regexp.MustCompile(`^\s*add\s+(x\d+),\s*(x\d+),\s*:lo12:(\S+)`)
+var regexpArm64Neon = regexp.MustCompile(`^\s*(ld1|st1)\s+\{v(\d+)\.(\d+)([bhsd])\},\s*\[(x\d+)\]`)
+
+// ARM64Arch translates clang-generated AArch64 (AAPCS64) assembly into Go's
+// ARM64 assembler dialect. It mirrors the AMD64 pipeline but speaks the
+// register names, branch mnemonics and addressing forms of ARM64.
+type ARM64Arch struct{}
+
+func (ARM64Arch) Name() string        { return "arm64" }
+func (ARM64Arch) Registers() []string { return arm64Registers[:] }
+
+// WritePrologue loads the first 8 integer arguments from the Go stack frame
+// into R0..R7, the registers the AAPCS64-compiled body expects its arguments
+// in (X0..X7 in the clang output, renamed to Go's Rn form).
+func (a ARM64Arch) WritePrologue(subroutine Subroutine, arguments int, table Table) []string {
+
+	var result []string
+
+	result = append(result, fmt.Sprintf("TEXT ·_%s(SB), 7, $0\n", subroutine.name))
+
+	for arg, reg := range arm64Registers {
+		result = append(result, fmt.Sprintf("    MOVD arg%d+%d(FP), %s", arg+1, arg*8, reg))
+		if arg+1 == arguments {
+			break
+		}
+	}
+
+	if table.isPresent() {
+		// Load the base register used to fold PIC adrp/add:lo12 pairs into
+		// offsets. R19 is used rather than R27 because R27 is Go's arm64
+		// REGTMP, reserved by the assembler to expand pseudo-instructions
+		// (large immediates, out-of-range branches); R19-R26 are the
+		// callee-saved registers Go leaves free for general use.
+		result = append(result, "", fmt.Sprintf("    MOVD $%s<>(SB), R19", table.Name), "")
+	}
+
+	return result
+}
+
+// WriteBody rewrites clang's AArch64 instructions into their Go asm equivalents.
+func (a ARM64Arch) WriteBody(lines []string, table Table, stackArgs StackArgs, epilogue Epilogue) ([]string, error) {
+
+	var result []string
+
+	for iline, line := range lines {
+
+		if iline >= epilogue.Start && iline < epilogue.End {
+			if iline == epilogue.End-1 {
+				result = append(result, a.WriteEpilogue(epilogue)...)
+			}
+			continue
+		}
+
+		var skipLine bool
+		line, skipLine = stripComments(line)
+		if skipLine {
+			continue
+		}
+
+		if strings.Contains(line, ".align") {
+			continue
+		}
+
+		decoded, err := DecodeInstruction(line, iline+1)
+		if err != nil {
+			return nil, err
+		}
+		if canonicalized := decoded.Canonicalize(); canonicalized.Mnemonic != decoded.Mnemonic {
+			line = strings.Replace(line, decoded.Mnemonic, canonicalized.Mnemonic, 1)
+		}
+
+		line, _ = fixArm64Labels(line)
+		line = upperCaseArm64Branches(line)
+		line = fixArm64Call(line)
+		line = fixArm64Neon(line)
+		if table.isPresent() {
+			line = fixArm64Pic(line, table)
+		}
+
+		fields := strings.Fields(line)
+		// Anything not rewritten above (add, sub, ldr, str, mov, cmp, ...) is
+		// still lower-case clang AArch64 syntax, not valid Go asm; comment it
+		// out, same as writeGoasmBody does for unhandled amd64 mnemonics, so
+		// downstream tooling can flag or re-encode it rather than assembling garbage.
+		if len(fields) > 0 && !strings.Contains(fields[0], ":") && isLower(fields[0]) {
+			line = "                                 // " + strings.TrimSpace(line)
+		}
+
+		result = append(result, line)
+	}
+
+	return result, nil
+}
+
+// WriteEpilogue returns out of the subroutine; ARM64 has no equivalent of
+// the AMD64 VZEROUPPER dance, so this is simply a RET.
+func (a ARM64Arch) WriteEpilogue(epilogue Epilogue) []string {
+	return []string{"    RET"}
+}
+
+// fixArm64Labels strips the leading `.` from clang's local labels, same as fixLabels does for amd64.
+func fixArm64Labels(line string) (string, string) {
+
+	label := ""
+
+	if match := regexpArm64Label.FindStringSubmatch(line); len(match) > 0 {
+		label = strings.Replace(match[1], ".", "", 1)
+		line = label
+		label = strings.Replace(label, ":", "", 1)
+	}
+
+	return line, label
+}
+
+// upperCaseArm64Branches rewrites lower-case AArch64 branch mnemonics (b, b.eq, cbz, tbz, ...)
+// into their upper-case Go assembler form, dropping the leading `.` from the target label.
+func upperCaseArm64Branches(line string) string {
+
+	if match := regexpArm64Branch.FindStringSubmatch(line); len(match) > 0 {
+		instruction := strings.ToUpper(match[1])
+		label := strings.Replace(match[4], ".", "", 1)
+		if match[3] != "" {
+			// tbz/tbnz: Go's assembler takes the bit number first, `TBZ $bit, Rn, label`.
+			line = fmt.Sprintf("%s $%s, %s, %s", instruction, match[3], strings.ToUpper(match[2]), label)
+		} else if match[2] != "" {
+			line = fmt.Sprintf("%s %s, %s", instruction, strings.ToUpper(match[2]), label)
+		} else {
+			line = fmt.Sprintf("%s %s", instruction, label)
+		}
+	}
+
+	return line
+}
+
+// fixArm64Call rewrites `bl _sym` into `CALL clib·sym(SB)`.
+func fixArm64Call(line string) string {
+
+	if match := regexpArm64Call.FindStringSubmatch(line); len(match) > 1 {
+		line = fmt.Sprintf("    CALL clib·%s(SB)", match[1])
+	}
+
+	return line
+}
+
+// fixArm64Neon translates NEON load/store mnemonics (`ld1`/`st1 {vN.Xb}, [xM]`) into
+// their Go assembler forms (VLD1/VST1), keeping the vector arrangement (eg `.16b`)
+// as the qualifier Go's ARM64 assembler expects on the vector register (`V0.B16`).
+// Go's assembler puts the memory operand last for a load but first for a
+// store, so the two mnemonics can't share one operand order: `VLD1 (Rn),
+// [Vt.T]` reads from memory into the vector register, while `VST1 [Vt.T],
+// (Rn)` writes the vector register out to memory.
+func fixArm64Neon(line string) string {
+
+	if match := regexpArm64Neon.FindStringSubmatch(line); len(match) > 0 {
+		mnemonic := "V" + strings.ToUpper(match[1])
+		arrangement := fmt.Sprintf("%s%s", strings.ToUpper(match[4]), match[3])
+		reg := fmt.Sprintf("V%s.%s", match[2], arrangement)
+		base := strings.ToUpper(match[5])
+		if match[1] == "st1" {
+			line = fmt.Sprintf("    %s [%s], (%s)", mnemonic, reg, base)
+		} else {
+			line = fmt.Sprintf("    %s (%s), [%s]", mnemonic, base, reg)
+		}
+	}
+
+	return line
+}
+
+// fixArm64Pic folds a clang `adrp`/`add :lo12:` pair into a single offset from
+// the base register loaded in the prologue, the same way fixPicLabels does for
+// rip-relative loads on amd64.
+func fixArm64Pic(line string, table Table) string {
+
+	if match := regexpArm64AddLo12.FindStringSubmatch(line); len(match) > 0 {
+		label := match[3]
+
+		i := -1
+		var l Label
+		for i, l = range table.Labels {
+			if l.Name == label {
+				line = fmt.Sprintf("    MOVD $%d(R19), %s /* :lo12:%s */", l.Offset, strings.ToUpper(match[1]), label)
+				break
+			}
+		}
+		if i == len(table.Labels) {
+			panic(fmt.Sprintf("Failed to find label to replace of position independent code: %s", label))
+		}
+		return line
+	}
+
+	if regexpArm64Adrp.MatchString(line) {
+		// The adrp half is folded into the paired add:lo12 rewrite above, so it contributes nothing on its own.
+		return "                                 // " + strings.TrimSpace(line)
+	}
+
+	return line
+}
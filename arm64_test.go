@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestFixArm64NeonOperandOrder verifies that ld1/st1 are translated into Go's
+// VLD1/VST1 forms with the operand order each actually requires: a load
+// reads from memory into the vector register ("(Rn), [Vt.T]"), a store
+// writes the vector register out to memory ("[Vt.T], (Rn)").
+func TestFixArm64NeonOperandOrder(t *testing.T) {
+
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{
+			name: "ld1",
+			line: "ld1 {v0.16b}, [x0]",
+			want: "VLD1 (X0), [V0.B16]",
+		},
+		{
+			name: "st1",
+			line: "st1 {v0.16b}, [x1]",
+			want: "VST1 [V0.B16], (X1)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := strings.TrimSpace(fixArm64Neon(test.line))
+			if got != test.want {
+				t.Errorf("fixArm64Neon(%q) = %q, want %q", test.line, got, test.want)
+			}
+		})
+	}
+}
+
+// TestWriteProloguePicAvoidsRegtmp verifies that the PIC constants-table base
+// register loaded across the whole function body is never R27, Go's arm64
+// REGTMP, which the assembler can clobber at any point to expand a
+// pseudo-instruction.
+func TestWriteProloguePicAvoidsRegtmp(t *testing.T) {
+
+	table := Table{Name: "const_table", Labels: []Label{{Name: "LCPI0_0", Offset: 0}}}
+	subroutine := Subroutine{name: "withtable"}
+
+	prologue := ARM64Arch{}.WritePrologue(subroutine, 1, table)
+
+	for _, line := range prologue {
+		if strings.Contains(line, "R27") {
+			t.Fatalf("prologue loads the PIC base into R27 (Go's REGTMP), must use a callee-saved register instead: %q", line)
+		}
+	}
+
+	body, err := ARM64Arch{}.WriteBody([]string{"add x0, x0, :lo12:LCPI0_0"}, table, StackArgs{}, Epilogue{Start: -1, End: -1})
+	if err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	for _, line := range body {
+		if strings.Contains(line, "R27") {
+			t.Fatalf("fixArm64Pic folds the PIC label through R27 (Go's REGTMP): %q", line)
+		}
+	}
+}
@@ -74,7 +74,7 @@ func writeGoasmPrologue(subroutine Subroutine, arguments int, table Table) []str
 	return result
 }
 
-func writeGoasmBody(lines []string, table Table, stackArgs StackArgs, epilogue Epilogue ) ([]string, error) {
+func writeGoasmBody(lines []string, table Table, stackArgs StackArgs, epilogue Epilogue, flavor Flavor) ([]string, error) {
 
 	var result []string
 
@@ -102,9 +102,17 @@ func writeGoasmBody(lines []string, table Table, stackArgs StackArgs, epilogue E
 			continue
 		}
 
-		line, _ = fixLabels(line)
-		line, _, _ = upperCaseJumps(line)
-		line = upperCaseCalls(line)
+		decoded, err := DecodeInstruction(line, iline+1)
+		if err != nil {
+			return nil, err
+		}
+		if canonicalized := decoded.Canonicalize(); canonicalized.Mnemonic != decoded.Mnemonic {
+			line = strings.Replace(line, decoded.Mnemonic, canonicalized.Mnemonic, 1)
+		}
+
+		line, _ = flavor.FixLabels(line)
+		line, _, _ = flavor.UpperCaseJumps(line)
+		line = flavor.UpperCaseCalls(line)
 
 		fields := strings.Fields(line)
 		// Test for any non-jmp instruction (lower case mnemonic)
@@ -113,18 +121,15 @@ func writeGoasmBody(lines []string, table Table, stackArgs StackArgs, epilogue E
 			line = "                                 // " + strings.TrimSpace(line)
 		}
 
-		line = removeUndefined(line, "ptr")
-		line = removeUndefined(line, "xmmword")
-		line = removeUndefined(line, "ymmword")
-		line = removeUndefined(line, "# NOREX")
+		line = flavor.StripSizeDirectives(line)
 
-		line = fixShiftInstructions(line)
-		line = fixMovabsInstructions(line)
+		line = flavor.FixShiftInstructions(line)
+		line = flavor.FixMovabsInstructions(line)
 		if table.isPresent() {
-			line = fixPicLabels(line, table)
+			line = flavor.FixPicLabels(line, table, decoded)
 		}
 
-		line = fixRbpPlusLoad(line, stackArgs, table.isPresent() && epilogue.AlignedStack)
+		line = flavor.FixRbpPlusLoad(line, stackArgs, table.isPresent() && epilogue.AlignedStack, decoded)
 		line = fixRbpMinusMemoryAccess(line)
 
 		result = append(result, line)
@@ -236,24 +241,32 @@ func removeUndefined(line, undef string) string {
 }
 
 // fix Position Independent Labels
-func fixPicLabels(line string, table Table) string {
-
-	if strings.Contains(line, "[rip + ") {
-		parts := strings.SplitN(line, "[rip + ", 2)
-		label := parts[1][:len(parts[1])-1]
-
-		i := -1
-		var l Label
-		for i, l = range table.Labels {
-			if l.Name == label {
-				line = parts[0] + fmt.Sprintf("%d[rbp] /* [rip + %s */", l.Offset, parts[1])
-				break
-			}
-		}
-		if i == len(table.Labels) {
-			panic(fmt.Sprintf("Failed to find label to replace of position independent code: %s", label))
+// fixPicLabels rewrites an Intel-syntax `[rip + label]` memory operand into
+// an offset from the constants-table base register. decoded is consulted
+// first to confirm "[rip + " names a genuine operand of this instruction -
+// not, say, text that survived inside a comment or a label - before line is
+// touched at all.
+func fixPicLabels(line string, table Table, decoded Instruction) string {
+
+	operand, ok := decoded.Operand("[rip + ")
+	if !ok {
+		return line
+	}
+
+	ripOperand := operand[strings.Index(operand, "[rip + "):]
+	label := strings.TrimSuffix(strings.TrimPrefix(ripOperand, "[rip + "), "]")
+
+	i := -1
+	var l Label
+	for i, l = range table.Labels {
+		if l.Name == label {
+			line = strings.Replace(line, ripOperand, fmt.Sprintf("%d[rbp] /* %s */", l.Offset, ripOperand), 1)
+			break
 		}
 	}
+	if i == len(table.Labels) {
+		panic(fmt.Sprintf("Failed to find label to replace of position independent code: %s", label))
+	}
 
 	return line
 }
@@ -292,19 +305,29 @@ func fixMovabsInstructions(line string) string {
 // Fix loads in the form of '[rbp + constant]'
 // These are load instructions for stack-based arguments that occur after the first 6 arguments
 // Remap to rsp/stack pointer and load from golang stack
-func fixRbpPlusLoad(line string, stackArgs StackArgs, argsBelowSP bool) string {
-
-	if match := regexpRbpLoadHigher.FindStringSubmatch(line); len(match) > 1 {
-		offset, _ := strconv.Atoi(match[1])
-		parts := strings.SplitN(line, "[rbp + ", 2)
-		if argsBelowSP {
-			offset -= (stackArgs.Number + 1 /* space for saved SP */ + stackArgs.OffsetToFirst/8) * 8
-			line = parts[0] + fmt.Sprintf("%d[rsp] /* [rbp + %s */", offset, parts[1])
-		} else {
-			offset = offset - stackArgs.OffsetToFirst + returnAddrOnStack + 8*len(registers)
-			line = parts[0] + fmt.Sprintf("%d[rsp] /* [rbp + %s */", offset, parts[1])
-		}
+// fixRbpPlusLoad rewrites an Intel-syntax `[rbp + N]` stack-argument load
+// into a Go stack-frame offset. decoded confirms "[rbp + " names a genuine
+// operand before the regexp below (which needs the full `[rbp + N]` match,
+// not just the fact the text is present) runs against it.
+func fixRbpPlusLoad(line string, stackArgs StackArgs, argsBelowSP bool, decoded Instruction) string {
+
+	operand, ok := decoded.Operand("[rbp + ")
+	if !ok {
+		return line
+	}
+
+	match := regexpRbpLoadHigher.FindStringSubmatch(operand)
+	if match == nil {
+		return line
+	}
+
+	offset, _ := strconv.Atoi(match[1])
+	if argsBelowSP {
+		offset -= (stackArgs.Number + 1 /* space for saved SP */ + stackArgs.OffsetToFirst/8) * 8
+	} else {
+		offset = offset - stackArgs.OffsetToFirst + returnAddrOnStack + 8*len(registers)
 	}
+	line = strings.Replace(line, match[0], fmt.Sprintf("%d[rsp] /* %s */", offset, match[0]), 1)
 
 	return line
 }
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// strictFlag makes the decoder pass fail on any mnemonic it doesn't
+// recognise instead of passing it through to the flavor rewrites verbatim.
+var strictFlag = flag.Bool("strict", false, "fail with a line-numbered error on unrecognised mnemonics instead of emitting them verbatim")
+
+// Instruction is a structural, flavor-agnostic view of one assembly line,
+// produced by DecodeInstruction ahead of the flavor-specific rewrites.
+// DisassembleObject (objectfile.go) renders golang.org/x/arch/x86/x86asm
+// decoding of compiled object code back into text lines, which reach this
+// same model through DecodeInstruction. Since that input is mnemonic/operand
+// text rather than machine code, DecodeInstruction lexes it by hand - x86asm has no
+// entry point that decodes assembly source text. Mnemonic canonicalization
+// (Canonicalize) operates on this structural form; the RIP-relative-operand
+// and stack-arg rewrites still need flavor- and table-specific context
+// DecodeInstruction doesn't carry, so the actual substitution is still text
+// based, but each rewrite first calls Operand to confirm the text it's
+// about to touch names a genuine operand of this instruction, not text that
+// merely survives inside a comment or a label.
+type Instruction struct {
+	Label    string // non-empty if the line is only a label definition
+	Mnemonic string
+	Operands []string
+	Comment  string
+	Line     int // 1-based source line number, for diagnostics
+}
+
+// DecodeError reports a line-numbered failure to parse or recognise an instruction.
+type DecodeError struct {
+	Line int
+	Text string
+	Err  string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("line %d: %s: %q", e.Line, e.Err, e.Text)
+}
+
+// knownMnemonics lists the base mnemonics the rewrite pipeline understands.
+// In -strict mode, anything else is reported as a DecodeError rather than
+// silently emitted verbatim.
+var knownMnemonics = map[string]bool{
+	"mov": true, "movabs": true, "movzx": true, "movsx": true, "lea": true,
+	"add": true, "sub": true, "and": true, "or": true, "xor": true, "not": true, "neg": true,
+	"shr": true, "sar": true, "shl": true,
+	"push": true, "pop": true, "call": true, "ret": true, "jmp": true,
+	"cmp": true, "test": true, "nop": true, "bl": true, "b": true,
+	"cbz": true, "cbnz": true, "tbz": true, "tbnz": true, "adrp": true,
+	"ld1": true, "st1": true,
+}
+
+// DecodeInstruction parses one assembly line into its structural form; a
+// trailing `# ...` comment (as stripComments recognises it) goes into
+// Comment, not into the operands. Label-only lines (`LBB0_1:`) come back
+// with Label set and everything else empty. In -strict mode an unrecognised
+// mnemonic is reported as an error carrying the 1-based source line number.
+func DecodeInstruction(line string, lineNumber int) (Instruction, error) {
+
+	instr := Instruction{Line: lineNumber}
+
+	trimmed := strings.TrimSpace(line)
+	if loc := regexpStripComments.FindStringIndex(trimmed); loc != nil {
+		instr.Comment = strings.TrimSpace(trimmed[loc[0]:])
+		trimmed = trimmed[:loc[0]]
+	}
+	if trimmed == "" {
+		return instr, nil
+	}
+
+	if strings.HasSuffix(trimmed, ":") && !strings.ContainsAny(trimmed, " \t") {
+		instr.Label = strings.TrimSuffix(trimmed, ":")
+		return instr, nil
+	}
+
+	fields := strings.SplitN(trimmed, " ", 2)
+	mnemonic := strings.ToLower(strings.TrimSuffix(fields[0], ":"))
+	instr.Mnemonic = mnemonic
+
+	if len(fields) > 1 {
+		for _, operand := range strings.Split(fields[1], ",") {
+			instr.Operands = append(instr.Operands, strings.TrimSpace(operand))
+		}
+	}
+
+	if *strictFlag && mnemonic != "" && !knownMnemonics[canonicalFamily(mnemonic)] {
+		return instr, &DecodeError{Line: lineNumber, Text: trimmed, Err: "unsupported mnemonic"}
+	}
+
+	return instr, nil
+}
+
+// attSizeSuffixes are the GAS operand-size suffixes (byte/word/long/quad)
+// AT&T mnemonics carry that Intel syntax doesn't, eg "movq", "addq", "sarl".
+var attSizeSuffixes = []string{"b", "w", "l", "q"}
+
+// canonicalFamily collapses the many conditional jump/branch mnemonics
+// (je, jne, b.eq, ...) down to their family name, and strips a GAS
+// operand-size suffix (movq -> mov, sarl -> sar) when doing so yields a
+// mnemonic the pipeline otherwise recognises, for the known-mnemonic check.
+func canonicalFamily(mnemonic string) string {
+	if strings.HasPrefix(mnemonic, "j") {
+		return "jmp"
+	}
+	if strings.HasPrefix(mnemonic, "b.") {
+		return "b"
+	}
+	for _, suffix := range attSizeSuffixes {
+		if base := strings.TrimSuffix(mnemonic, suffix); base != mnemonic && knownMnemonics[base] {
+			return base
+		}
+	}
+	return mnemonic
+}
+
+// Canonicalize rewrites mnemonics that are pure spelling variants of another
+// one the pipeline already knows how to handle - currently just movabs,
+// which is mov with a 64-bit immediate. It operates on the parsed mnemonic
+// rather than matching text, so it can't misfire on a mnemonic that
+// merely appears inside a comment or a quoted string.
+func (i Instruction) Canonicalize() Instruction {
+	if i.Mnemonic == "movabs" {
+		i.Mnemonic = "mov"
+	}
+	return i
+}
+
+// Operand returns the first operand containing substr, or ok=false if none
+// does. A rewrite calls this before matching substr against the raw line,
+// so it can't misfire on text that happens to appear in a comment or label
+// rather than in an actual operand.
+func (i Instruction) Operand(substr string) (operand string, ok bool) {
+	for _, operand := range i.Operands {
+		if strings.Contains(operand, substr) {
+			return operand, true
+		}
+	}
+	return "", false
+}
+
+// String reassembles an Instruction back into an assembly line.
+func (i Instruction) String() string {
+
+	if i.Label != "" {
+		return i.Label + ":"
+	}
+	if i.Mnemonic == "" {
+		return i.Comment
+	}
+
+	line := i.Mnemonic
+	if len(i.Operands) > 0 {
+		line += " " + strings.Join(i.Operands, ", ")
+	}
+	if i.Comment != "" {
+		line += " " + i.Comment
+	}
+
+	return line
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+// TestFixPicLabelsIgnoresRipTextOutsideOperands verifies that fixPicLabels
+// only rewrites "[rip + label]" when DecodeInstruction's structural operand
+// list actually contains it, so the same text appearing in, say, a trailing
+// comment can't be mistaken for a real RIP-relative memory operand.
+func TestFixPicLabelsIgnoresRipTextOutsideOperands(t *testing.T) {
+
+	table := Table{Labels: []Label{{Name: "LCPI0_0", Offset: 16}}}
+
+	line := "mov eax, ebx # not a real [rip + LCPI0_0] operand"
+	decoded, err := DecodeInstruction(line, 1)
+	if err != nil {
+		t.Fatalf("DecodeInstruction: %v", err)
+	}
+
+	got := fixPicLabels(line, table, decoded)
+	if got != line {
+		t.Errorf("fixPicLabels rewrote RIP-relative text outside any operand: got %q, want unchanged %q", got, line)
+	}
+}
+
+// TestFixPicLabelsRewritesGenuineOperand is the positive counterpart: a real
+// `[rip + label]` memory operand is still rewritten to the table offset.
+func TestFixPicLabelsRewritesGenuineOperand(t *testing.T) {
+
+	table := Table{Labels: []Label{{Name: "LCPI0_0", Offset: 16}}}
+
+	line := "mov eax, [rip + LCPI0_0]"
+	decoded, err := DecodeInstruction(line, 1)
+	if err != nil {
+		t.Fatalf("DecodeInstruction: %v", err)
+	}
+
+	got := fixPicLabels(line, table, decoded)
+	want := "mov eax, 16[rbp] /* [rip + LCPI0_0] */"
+	if got != want {
+		t.Errorf("fixPicLabels(%q) = %q, want %q", line, got, want)
+	}
+}
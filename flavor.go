@@ -0,0 +1,269 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// flavorFlag selects the input assembly syntax writeGoasmBody expects to see.
+var flavorFlag = flag.String("flavor", "", "input assembly syntax: llvm-intel (clang -masm=intel, default) or gnu-att (gcc -S)")
+
+// Flavor knows how to recognise and rewrite the constructs that differ
+// between assembler dialects: label/jump/call syntax, size-directive
+// cruft, shift/movabs spelling, RIP-relative addressing and stack-argument
+// loads. writeGoasmBody drives a Flavor rather than hard-coding any one
+// dialect's regexes.
+type Flavor interface {
+	// Name returns the short identifier used on the command line (eg "llvm-intel").
+	Name() string
+	// Detect reports whether line looks like it was written in this flavor.
+	Detect(line string) bool
+	// FixLabels strips the leading `.` from a local label definition, if line is one.
+	FixLabels(line string) (string, string)
+	// UpperCaseJumps upper-cases a jmp/Jcc instruction and its target label, if line is one.
+	UpperCaseJumps(line string) (string, string, string)
+	// UpperCaseCalls upper-cases a call instruction and rewrites known libc symbols.
+	UpperCaseCalls(line string) string
+	// StripSizeDirectives removes dialect-specific size/segment cruft (eg Intel's `ptr`, `xmmword`).
+	StripSizeDirectives(line string) string
+	// FixShiftInstructions makes an implicit shift count of 1 explicit.
+	FixShiftInstructions(line string) string
+	// FixMovabsInstructions rewrites a 64-bit immediate move into a plain mov.
+	FixMovabsInstructions(line string) string
+	// FixPicLabels rewrites a RIP-relative operand into an offset from the constants base
+	// register. decoded is the structural view of line (see DecodeInstruction), consulted to
+	// confirm the RIP-relative text names a genuine operand before line is rewritten.
+	FixPicLabels(line string, table Table, decoded Instruction) string
+	// FixRbpPlusLoad rewrites a stack-based argument load into a Go stack-frame offset.
+	// decoded is consulted the same way FixPicLabels does.
+	FixRbpPlusLoad(line string, stackArgs StackArgs, argsBelowSP bool, decoded Instruction) string
+}
+
+// SelectFlavor resolves the -flavor flag (or an explicit name) to its Flavor implementation.
+func SelectFlavor(name string) (Flavor, error) {
+	switch name {
+	case "llvm-intel", "":
+		return LLVMIntel{}, nil
+	case "gnu-att":
+		return GNUATT{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported flavor %q: expected llvm-intel or gnu-att", name)
+	}
+}
+
+// DetectFlavor picks a Flavor by scanning lines for the first operand-bearing
+// instruction and asking each candidate flavor whether it recognises it.
+func DetectFlavor(lines []string) Flavor {
+
+	candidates := []Flavor{LLVMIntel{}, GNUATT{}}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		if len(strings.Fields(trimmed)) < 2 {
+			continue
+		}
+		for _, flavor := range candidates {
+			if flavor.Detect(trimmed) {
+				return flavor
+			}
+		}
+	}
+
+	// Default to the original, best-understood dialect when nothing matched.
+	return LLVMIntel{}
+}
+
+// LLVMIntel is clang's `-masm=intel` output: `[rip + label]`, `qword ptr`,
+// `.LBB` labels. This is the dialect c2goasm originally supported, and its
+// methods simply delegate to the pre-existing package-level helpers.
+type LLVMIntel struct{}
+
+func (LLVMIntel) Name() string { return "llvm-intel" }
+
+func (LLVMIntel) Detect(line string) bool {
+	return strings.Contains(line, "ptr") || strings.Contains(line, "[rip") || regexpLabel.MatchString(line)
+}
+
+func (LLVMIntel) FixLabels(line string) (string, string) { return fixLabels(line) }
+
+func (LLVMIntel) UpperCaseJumps(line string) (string, string, string) { return upperCaseJumps(line) }
+
+func (LLVMIntel) UpperCaseCalls(line string) string { return upperCaseCalls(line) }
+
+func (LLVMIntel) StripSizeDirectives(line string) string {
+	line = removeUndefined(line, "ptr")
+	line = removeUndefined(line, "xmmword")
+	line = removeUndefined(line, "ymmword")
+	line = removeUndefined(line, "# NOREX")
+	return line
+}
+
+func (LLVMIntel) FixShiftInstructions(line string) string { return fixShiftInstructions(line) }
+
+func (LLVMIntel) FixMovabsInstructions(line string) string { return fixMovabsInstructions(line) }
+
+func (LLVMIntel) FixPicLabels(line string, table Table, decoded Instruction) string {
+	return fixPicLabels(line, table, decoded)
+}
+
+func (LLVMIntel) FixRbpPlusLoad(line string, stackArgs StackArgs, argsBelowSP bool, decoded Instruction) string {
+	if *callconvFlag == "msvc" {
+		return fixRbpPlusLoadMSVC(line, stackArgs, argsBelowSP, decoded)
+	}
+	return fixRbpPlusLoad(line, stackArgs, argsBelowSP, decoded)
+}
+
+// GNUATT is `gcc -S` (or clang without -masm=intel) output: `%rax` registers,
+// `$imm` immediates, `offset(%rip)` addressing, `.L` labels and reversed
+// (src, dst) operand order.
+type GNUATT struct{}
+
+var regexpAttRegister = regexp.MustCompile(`%(\w+)`)
+var regexpAttLabel = regexp.MustCompile(`^(\.L\w+:)`)
+var regexpAttJumpWithLabel = regexp.MustCompile(`^(\s*j\w*)\s*(\.L\w+)`)
+var regexpAttCall = regexp.MustCompile(`^\s*call\s*`)
+var regexpAttRipOperand = regexp.MustCompile(`([\w.$]+)\(%rip\)`)
+var regexpAttRbpLoadHigher = regexp.MustCompile(`(\d+)\(%rbp\)\s*$`)
+var regexpAttShiftNoArgument = regexp.MustCompile(`^(\s*s[ah]r)([bwlq]?)\s+(%\w+)\s*$`)
+var regexpAttMovabs = regexp.MustCompile(`^(\s*)movabs([bwlq]?)(\s+)`)
+
+func (GNUATT) Name() string { return "gnu-att" }
+
+func (GNUATT) Detect(line string) bool {
+	return regexpAttRegister.MatchString(line) || regexpAttLabel.MatchString(line)
+}
+
+// FixLabels strips the leading `.` from a GAS `.L...:` label.
+func (GNUATT) FixLabels(line string) (string, string) {
+
+	label := ""
+
+	if match := regexpAttLabel.FindStringSubmatch(line); len(match) > 0 {
+		label = strings.Replace(match[1], ".", "", 1)
+		line = label
+		label = strings.Replace(label, ":", "", 1)
+	}
+
+	return line, label
+}
+
+func (GNUATT) UpperCaseJumps(line string) (string, string, string) {
+
+	instruction, label := "", ""
+
+	if match := regexpAttJumpWithLabel.FindStringSubmatch(line); len(match) > 1 {
+		instruction = strings.ToUpper(match[1])
+		label = strings.Replace(match[2], ".", "", 1)
+		line = instruction + " " + label
+	}
+
+	return line, strings.TrimSpace(instruction), label
+}
+
+func (GNUATT) UpperCaseCalls(line string) string {
+
+	if match := regexpAttCall.FindStringSubmatch(line); len(match) > 0 {
+		parts := strings.SplitN(line, "call", 2)
+
+		symbol := strings.TrimSpace(parts[1])
+		if symbol == "memcpy" {
+			symbol = fmt.Sprintf("clib·%s(SB)", symbol)
+		}
+		line = parts[0] + "CALL " + symbol
+	}
+
+	return line
+}
+
+// StripSizeDirectives is a no-op for AT&T syntax: GAS encodes operand size in
+// the mnemonic suffix (movq, movl, ...) rather than a separate `ptr` keyword.
+func (GNUATT) StripSizeDirectives(line string) string { return line }
+
+// FixShiftInstructions makes an implicit shift count of 1 explicit, same as the Intel flavor.
+// AT&T syntax puts the (now explicit) count before the shifted operand, eg
+// `sarq %rax` becomes `sarq $1, %rax`.
+func (GNUATT) FixShiftInstructions(line string) string {
+
+	if match := regexpAttShiftNoArgument.FindStringSubmatch(line); len(match) > 0 {
+		line = fmt.Sprintf("%s%s $1, %s", match[1], match[2], strings.TrimSpace(match[3]))
+	}
+
+	return line
+}
+
+// FixMovabsInstructions rewrites AT&T's `movabsq $imm, %reg` into a plain `mov`.
+func (GNUATT) FixMovabsInstructions(line string) string {
+
+	if match := regexpAttMovabs.FindStringSubmatch(line); len(match) > 0 {
+		line = match[1] + "mov" + match[2] + match[3] + line[len(match[0]):]
+	}
+
+	return line
+}
+
+// FixPicLabels rewrites a `label(%rip)` operand into an offset from the
+// constants base register. decoded confirms "(%rip)" names a genuine
+// operand of this instruction before line is rewritten.
+func (GNUATT) FixPicLabels(line string, table Table, decoded Instruction) string {
+
+	operand, ok := decoded.Operand("(%rip)")
+	if !ok {
+		return line
+	}
+
+	match := regexpAttRipOperand.FindStringSubmatch(operand)
+	if match == nil {
+		return line
+	}
+	label := match[1]
+
+	i := -1
+	var l Label
+	for i, l = range table.Labels {
+		if l.Name == label {
+			line = strings.Replace(line, match[0], fmt.Sprintf("%d(BP) /* %s */", l.Offset, match[0]), 1)
+			break
+		}
+	}
+	if i == len(table.Labels) {
+		panic(fmt.Sprintf("Failed to find label to replace of position independent code: %s", label))
+	}
+
+	return line
+}
+
+// FixRbpPlusLoad rewrites AT&T's `N(%rbp)` stack-argument loads the same way
+// the Intel flavor rewrites `[rbp + N]`. decoded confirms "(%rbp)" names a
+// genuine operand before the regexp below runs against it.
+func (GNUATT) FixRbpPlusLoad(line string, stackArgs StackArgs, argsBelowSP bool, decoded Instruction) string {
+
+	if *callconvFlag == "msvc" {
+		return fixRbpPlusLoadMSVCAtt(line, stackArgs, argsBelowSP, decoded)
+	}
+
+	operand, ok := decoded.Operand("(%rbp)")
+	if !ok {
+		return line
+	}
+
+	match := regexpAttRbpLoadHigher.FindStringSubmatch(operand)
+	if match == nil {
+		return line
+	}
+
+	offset := 0
+	fmt.Sscanf(match[1], "%d", &offset)
+	if argsBelowSP {
+		offset -= (stackArgs.Number + 1 + stackArgs.OffsetToFirst/8) * 8
+	} else {
+		offset = offset - stackArgs.OffsetToFirst + returnAddrOnStack + 8*len(registers)
+	}
+	line = strings.Replace(line, match[0], fmt.Sprintf("%d(SP) /* %s */", offset, match[0]), 1)
+
+	return line
+}
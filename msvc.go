@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// callconvFlag selects the calling convention the clang/MSVC-compiled body
+// was generated against. "sysv" (the default) is System V AMD64, as used by
+// clang/gcc on Linux and macOS; "msvc" is the Microsoft x64 convention used
+// by MSVC and clang-cl on Windows.
+var callconvFlag = flag.String("callconv", "sysv", "calling convention of the input assembly: sysv (default) or msvc")
+
+// msvcShadowSpace is the 32 bytes of scratch space the Microsoft x64
+// convention reserves above the return address for the callee to spill its
+// register arguments into, whether or not it actually does so.
+const msvcShadowSpace = 32
+
+// msvcRegisters holds the first four integer/pointer argument registers of
+// the Microsoft x64 convention (RCX, RDX, R8, R9), named the way the Go
+// assembler spells them.
+var msvcRegisters = [...]string{"CX", "DX", "R8", "R9"}
+
+// writeGoasmPrologueMSVC is writeGoasmPrologue's counterpart for bodies
+// compiled against the Microsoft x64 convention: it loads the first four
+// arguments into RCX/RDX/R8/R9 instead of the System V registers, reserves
+// the 32-byte shadow space above the (simulated) return address that the
+// callee is entitled to spill into, and - like writeGoasmPrologue - handles
+// an aligned stack (eg for AVX temporaries) the same way.
+func writeGoasmPrologueMSVC(subroutine Subroutine, arguments int, table Table) []string {
+
+	var result []string
+
+	result = append(result, fmt.Sprintf("TEXT ·_%s(SB), 7, $0\n", subroutine.name))
+
+	if subroutine.epilogue.AlignedStack {
+		// Save original stack pointer right below newly aligned stack pointer
+		result = append(result, fmt.Sprintf("    MOVQ SP, BP"))
+		result = append(result, fmt.Sprintf("    ANDQ $%d, BP", subroutine.epilogue.AlignValue))
+		result = append(result, fmt.Sprintf("    SUBQ $%d, BP", subroutine.epilogue.StackSize))
+		result = append(result, fmt.Sprintf("    MOVQ SP, -8(BP)")) // Save original SP
+
+		if table.isPresent() {
+			for arg := arguments - 1; arg >= len(msvcRegisters); arg-- {
+				result = append(result, fmt.Sprintf("    MOVQ arg%d+%d(FP), CX", arg+1, arg*8))
+				result = append(result, fmt.Sprintf("    MOVQ CX, %d(BP)", -8+(arguments-arg)*-8))
+			}
+		}
+	}
+
+	for arg, reg := range msvcRegisters {
+		result = append(result, fmt.Sprintf("    MOVQ arg%d+%d(FP), %s", arg+1, arg*8, reg))
+		if arg+1 == arguments {
+			break
+		}
+	}
+
+	if table.isPresent() {
+		result = append(result, "", fmt.Sprintf("    LEAQ %s<>(SB), BP", table.Name), "")
+	} else if subroutine.epilogue.AlignedStack {
+		result = append(result, "", fmt.Sprintf("    MOVQ SP, BP"), "")
+	}
+
+	stackSize := subroutine.epilogue.StackSize + msvcShadowSpace
+	if subroutine.epilogue.AlignedStack {
+		result = append(result, fmt.Sprintf("    ANDQ $%d, SP", subroutine.epilogue.AlignValue))
+	}
+	result = append(result, fmt.Sprintf("    SUBQ $%d, SP", stackSize))
+
+	return result
+}
+
+// msvcRbpOffset computes the Go stack-frame offset a `rbp`-relative
+// stack-argument load (arg 5+, since the first four travel in registers)
+// must be rewritten to, the same two ways fixRbpPlusLoad does for the
+// System V convention: argsBelowSP true means the golang stack-based
+// arguments were copied below an aligned stack pointer in the prologue (see
+// writeGoasmPrologueMSVC), and false means they're read directly from the Go
+// arg slots. Either way, the 32-byte shadow space MSVC reserves above the
+// return address shifts the offset compared to the System V formula.
+func msvcRbpOffset(offset int, stackArgs StackArgs, argsBelowSP bool) int {
+	if argsBelowSP {
+		return offset - (stackArgs.Number+1 /* space for saved SP */ +stackArgs.OffsetToFirst/8)*8
+	}
+	return offset - stackArgs.OffsetToFirst + returnAddrOnStack + msvcShadowSpace + 8*len(msvcRegisters)
+}
+
+// fixRbpPlusLoadMSVC rewrites an Intel-syntax `[rbp + N]` stack-argument
+// load into a Go stack-frame offset under the Microsoft x64 convention.
+// decoded confirms "[rbp + " names a genuine operand before the regexp
+// below runs against it.
+func fixRbpPlusLoadMSVC(line string, stackArgs StackArgs, argsBelowSP bool, decoded Instruction) string {
+
+	operand, ok := decoded.Operand("[rbp + ")
+	if !ok {
+		return line
+	}
+
+	match := regexpRbpLoadHigher.FindStringSubmatch(operand)
+	if match == nil {
+		return line
+	}
+
+	offset := 0
+	fmt.Sscanf(match[1], "%d", &offset)
+	offset = msvcRbpOffset(offset, stackArgs, argsBelowSP)
+	line = strings.Replace(line, match[0], fmt.Sprintf("%d[rsp] /* %s */", offset, match[0]), 1)
+
+	return line
+}
+
+// fixRbpPlusLoadMSVCAtt rewrites an AT&T-syntax `N(%rbp)` stack-argument
+// load into a Go stack-frame offset under the Microsoft x64 convention, the
+// same way fixRbpPlusLoadMSVC does for Intel syntax.
+func fixRbpPlusLoadMSVCAtt(line string, stackArgs StackArgs, argsBelowSP bool, decoded Instruction) string {
+
+	operand, ok := decoded.Operand("(%rbp)")
+	if !ok {
+		return line
+	}
+
+	match := regexpAttRbpLoadHigher.FindStringSubmatch(operand)
+	if match == nil {
+		return line
+	}
+
+	offset := 0
+	fmt.Sscanf(match[1], "%d", &offset)
+	offset = msvcRbpOffset(offset, stackArgs, argsBelowSP)
+	line = strings.Replace(line, match[0], fmt.Sprintf("%d(SP) /* %s */", offset, match[0]), 1)
+
+	return line
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// regexpObjectRipOperand matches the RIP-relative operand x86asm's AT&T
+// renderer prints for a disassembled instruction (eg "0x0(%rip)"); the
+// literal displacement is meaningless once the backing relocation is
+// resolved to a symbol name, so it's replaced wholesale.
+var regexpObjectRipOperand = regexp.MustCompile(`-?(?:0x)?[0-9a-fA-F]*\(%rip\)`)
+
+// objectFlag points at a compiled .o/.obj file to read the subroutine body
+// from, instead of clang's .s text output. This lets a kernel ship as a
+// precompiled object alongside its Go source, without requiring clang at
+// build time.
+var objectFlag = flag.String("object", "", "read the subroutine from this compiled ELF .o file instead of a clang .s file (Mach-O and COFF objects are rejected with an error)")
+
+// objectSymbol holds the bytes and relocations of one function extracted
+// from a compiled object file, ready to be disassembled.
+type objectSymbol struct {
+	Name string
+	Code []byte
+	// Relocs maps a byte offset within Code to the symbol it relocates against.
+	Relocs map[int]string
+}
+
+// ReadObjectFile extracts symbol's code and relocations from an ELF object
+// file. Mach-O and COFF files are recognised, but return an error rather
+// than a symbol.
+func ReadObjectFile(path, symbol string) (objectSymbol, error) {
+
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		return readELFSymbol(f, symbol)
+	}
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		return readMachOSymbol(f, symbol)
+	}
+	if f, err := pe.Open(path); err == nil {
+		defer f.Close()
+		return readPESymbol(f, symbol)
+	}
+
+	return objectSymbol{}, fmt.Errorf("%s: unrecognised object file format (expected ELF, Mach-O or COFF)", path)
+}
+
+func readELFSymbol(f *elf.File, symbol string) (objectSymbol, error) {
+
+	text := f.Section(".text")
+	if text == nil {
+		return objectSymbol{}, fmt.Errorf("no .text section")
+	}
+	data, err := text.Data()
+	if err != nil {
+		return objectSymbol{}, err
+	}
+
+	syms, err := f.Symbols()
+	if err != nil {
+		return objectSymbol{}, err
+	}
+
+	textIndex := sectionIndex(f, text)
+
+	for _, sym := range syms {
+		if sym.Name != symbol {
+			continue
+		}
+		if int(sym.Section) != textIndex {
+			continue
+		}
+		if sym.Value+sym.Size > uint64(len(data)) {
+			return objectSymbol{}, fmt.Errorf("symbol %s: size %d at offset %d overruns .text (%d bytes)", symbol, sym.Size, sym.Value, len(data))
+		}
+		code := data[sym.Value : sym.Value+sym.Size]
+
+		relocs := make(map[int]string)
+		if relSection := f.Section(".rela.text"); relSection != nil {
+			rels, err := readELFRelocations(f, relSection)
+			if err != nil {
+				return objectSymbol{}, err
+			}
+			for _, rel := range rels {
+				if rel.Offset >= sym.Value && rel.Offset < sym.Value+sym.Size {
+					relocs[int(rel.Offset-sym.Value)] = rel.Symbol
+				}
+			}
+		}
+
+		return objectSymbol{Name: symbol, Code: code, Relocs: relocs}, nil
+	}
+
+	return objectSymbol{}, fmt.Errorf("symbol %s not found in .text", symbol)
+}
+
+// sectionIndex returns section's (0-based) index into f.Sections, which
+// keeps the null section at index 0, so it matches the section header
+// numbering elf.Symbol.Section uses to identify which section a symbol lives in.
+func sectionIndex(f *elf.File, section *elf.Section) int {
+	for i, s := range f.Sections {
+		if s == section {
+			return i
+		}
+	}
+	return -1
+}
+
+// elfRelocation is a relocation entry rebased to the symbol name it targets.
+type elfRelocation struct {
+	Offset uint64
+	Symbol string
+}
+
+// readELFRelocations parses an ELF64 RELA section by hand: the debug/elf
+// package exposes the relocated symbol table but not a generic decoder for
+// arbitrary relocation sections, so the raw Elf64_Rela entries are read
+// directly and resolved against the regular symbol table (f.Symbols).
+func readELFRelocations(f *elf.File, relSection *elf.Section) ([]elfRelocation, error) {
+
+	raw, err := relSection.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	syms, err := f.Symbols()
+	if err != nil {
+		return nil, err
+	}
+
+	const relaEntrySize = 24 // Elf64_Rela: r_offset, r_info, r_addend, each 8 bytes
+	var relocs []elfRelocation
+
+	reader := bytes.NewReader(raw)
+	for reader.Len() >= relaEntrySize {
+		var entry struct {
+			Offset uint64
+			Info   uint64
+			Addend int64
+		}
+		if err := binary.Read(reader, f.ByteOrder, &entry); err != nil {
+			return nil, err
+		}
+
+		symIndex := entry.Info >> 32
+		if symIndex == 0 || int(symIndex) > len(syms) {
+			continue
+		}
+		relocs = append(relocs, elfRelocation{Offset: entry.Offset, Symbol: syms[symIndex-1].Name})
+	}
+
+	return relocs, nil
+}
+
+func readMachOSymbol(f *macho.File, symbol string) (objectSymbol, error) {
+	return objectSymbol{}, fmt.Errorf("Mach-O object files are not yet supported, only ELF")
+}
+
+func readPESymbol(f *pe.File, symbol string) (objectSymbol, error) {
+	return objectSymbol{}, fmt.Errorf("COFF object files are not yet supported, only ELF")
+}
+
+// DisassembleObject decodes obj's machine code with x86asm and reconstructs
+// it into the same line-oriented assembly text the rest of the pipeline
+// expects from a clang .s file, so it can be fed through DecodeInstruction
+// and a single Flavor's rewrites unchanged. Every instruction is rendered in
+// AT&T syntax (GNUSyntax) so the reconstructed stream is uniform and matches
+// the GNUATT flavor end to end; relocations become either a CALL to the
+// relocated symbol, or (when the symbol matches a Table entry) the same
+// instruction with its RIP-relative operand's displacement replaced by the
+// symbol name.
+func DisassembleObject(obj objectSymbol, table Table) ([]string, error) {
+
+	var lines []string
+
+	for offset := 0; offset < len(obj.Code); {
+		inst, err := x86asm.Decode(obj.Code[offset:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s+%#x: %v", obj.Name, offset, err)
+		}
+
+		// A relocation sits at the displacement/immediate field inside the
+		// instruction, never at its first (opcode) byte.
+		relSymbol, ok := "", false
+		for i := offset; i < offset+inst.Len && !ok; i++ {
+			relSymbol, ok = obj.Relocs[i]
+		}
+		if ok {
+			if relSymbol == "memcpy" {
+				lines = append(lines, fmt.Sprintf("    CALL clib·%s(SB)", relSymbol))
+			} else if isTableConstant(relSymbol, table) {
+				// Keep the full instruction (mnemonic and destination operand),
+				// only replacing the RIP-relative source operand's displacement
+				// with the symbol name that GNUATT.FixPicLabels looks for.
+				decoded := strings.ToLower(x86asm.GNUSyntax(inst, uint64(offset), nil))
+				decoded = regexpObjectRipOperand.ReplaceAllString(decoded, fmt.Sprintf("%s(%%rip)", relSymbol))
+				lines = append(lines, "    "+decoded)
+			} else {
+				lines = append(lines, fmt.Sprintf("    CALL %s(SB)", relSymbol))
+			}
+		} else {
+			lines = append(lines, "    "+strings.ToLower(x86asm.GNUSyntax(inst, uint64(offset), nil)))
+		}
+
+		offset += inst.Len
+	}
+
+	return lines, nil
+}
+
+func isTableConstant(name string, table Table) bool {
+	for _, l := range table.Labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}